@@ -0,0 +1,135 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AzurePublicCloud       = "AzurePublicCloud"
+	AzureUSGovernmentCloud = "AzureUSGovernmentCloud"
+	AzureChinaCloud        = "AzureChinaCloud"
+	AzureStackCloud        = "AzureStackCloud"
+)
+
+// CloudConfig describes the AAD authority and MS Graph endpoints for a
+// particular Azure cloud environment. The well-known clouds are hard-coded in
+// cloudConfigs; AzureStackCloud is read from the JSON file pointed at by
+// --azure.cloud-config-file since its endpoints are deployment-specific.
+type CloudConfig struct {
+	// ActiveDirectoryEndpoint is the AAD authority host, e.g.
+	// https://login.microsoftonline.com
+	ActiveDirectoryEndpoint string `json:"activeDirectoryEndpoint"`
+	// GraphResourceID is the MS Graph resource URI used as the token audience
+	// and IMDS resource parameter, e.g. https://graph.microsoft.com/
+	GraphResourceID string `json:"graphResourceID"`
+	// ArcOBODomainSuffix is the DNS suffix of the ARC Hybrid Connectivity OBO
+	// service for this cloud, e.g. obo.arc.azure.com.
+	ArcOBODomainSuffix string `json:"arcOBODomainSuffix"`
+}
+
+var cloudConfigs = map[string]CloudConfig{
+	AzurePublicCloud: {
+		ActiveDirectoryEndpoint: "https://login.microsoftonline.com",
+		GraphResourceID:         "https://graph.microsoft.com/",
+		ArcOBODomainSuffix:      "obo.arc.azure.com",
+	},
+	AzureUSGovernmentCloud: {
+		ActiveDirectoryEndpoint: "https://login.microsoftonline.us",
+		GraphResourceID:         "https://graph.microsoft.us/",
+		ArcOBODomainSuffix:      "obo.arc.azure.us",
+	},
+	AzureChinaCloud: {
+		ActiveDirectoryEndpoint: "https://login.partner.microsoftonline.cn",
+		GraphResourceID:         "https://microsoftgraph.chinacloudapi.cn/",
+		ArcOBODomainSuffix:      "obo.arc.azure.cn",
+	},
+}
+
+// ARCOBOEndpoint builds the region- and cloud-specific URL of the ARC Hybrid
+// Connectivity on-behalf-of service for the connected cluster identified by
+// resourceID, per azure.auth-resource-id / azure.region.
+func (c CloudConfig) ARCOBOEndpoint(region, resourceID string) (string, error) {
+	if region == "" || resourceID == "" {
+		return "", errors.New("region and resourceID are required to build the arc obo endpoint")
+	}
+	return fmt.Sprintf("https://%s.%s/%s/getToken", region, c.ArcOBODomainSuffix, strings.TrimPrefix(resourceID, "/")), nil
+}
+
+// ExpectedTokenAudiences returns the token audience values guard should
+// accept for clientID in this cloud when azure.verify-clientID is set. AAD
+// v2.0 tokens carry the bare client ID as their audience in every cloud, but
+// guard also accepts the v1.0 "api://<clientID>" form for compatibility with
+// older app registrations.
+func (c CloudConfig) ExpectedTokenAudiences(clientID string) []string {
+	return []string{clientID, "api://" + clientID}
+}
+
+// VerifyClientIDAudience reports whether tokenAudience is an acceptable
+// audience for o.ClientID in o.Environment. It is a no-op returning true when
+// azure.verify-clientID is unset.
+func VerifyClientIDAudience(o Options, tokenAudience string) (bool, error) {
+	if !o.VerifyClientID {
+		return true, nil
+	}
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		return false, err
+	}
+	for _, expected := range cloud.ExpectedTokenAudiences(o.ClientID) {
+		if expected == tokenAudience {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveCloudConfig returns the CloudConfig for o.Environment, reading it
+// from o.CloudConfigFile for AzureStackCloud.
+func resolveCloudConfig(o Options) (CloudConfig, error) {
+	if o.Environment == AzureStackCloud {
+		return loadCloudConfigFile(o.CloudConfigFile)
+	}
+
+	cfg, ok := cloudConfigs[o.Environment]
+	if !ok {
+		return CloudConfig{}, errors.Errorf("unknown azure.environment %q", o.Environment)
+	}
+	return cfg, nil
+}
+
+func loadCloudConfigFile(path string) (CloudConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CloudConfig{}, errors.Wrapf(err, "failed to read azure.cloud-config-file %s", path)
+	}
+
+	var cfg CloudConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return CloudConfig{}, errors.Wrapf(err, "failed to unmarshal azure.cloud-config-file %s", path)
+	}
+	if cfg.ActiveDirectoryEndpoint == "" || cfg.GraphResourceID == "" {
+		return CloudConfig{}, errors.Errorf("azure.cloud-config-file %s must set both activeDirectoryEndpoint and graphResourceID", path)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCloudConfig(t *testing.T) {
+	stackConfigDir := t.TempDir()
+	validStackConfig := filepath.Join(stackConfigDir, "valid.json")
+	if err := os.WriteFile(validStackConfig, []byte(`{"activeDirectoryEndpoint":"https://login.stack.example","graphResourceID":"https://graph.stack.example/"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	incompleteStackConfig := filepath.Join(stackConfigDir, "incomplete.json")
+	if err := os.WriteFile(incompleteStackConfig, []byte(`{"activeDirectoryEndpoint":"https://login.stack.example"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	malformedStackConfig := filepath.Join(stackConfigDir, "malformed.json")
+	if err := os.WriteFile(malformedStackConfig, []byte(`not json`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		o       Options
+		wantErr bool
+	}{
+		{name: "public cloud", o: Options{Environment: AzurePublicCloud}},
+		{name: "us government cloud", o: Options{Environment: AzureUSGovernmentCloud}},
+		{name: "china cloud", o: Options{Environment: AzureChinaCloud}},
+		{name: "unknown environment", o: Options{Environment: "NotACloud"}, wantErr: true},
+		{name: "azure stack cloud with valid file", o: Options{Environment: AzureStackCloud, CloudConfigFile: validStackConfig}},
+		{name: "azure stack cloud with incomplete file", o: Options{Environment: AzureStackCloud, CloudConfigFile: incompleteStackConfig}, wantErr: true},
+		{name: "azure stack cloud with malformed file", o: Options{Environment: AzureStackCloud, CloudConfigFile: malformedStackConfig}, wantErr: true},
+		{name: "azure stack cloud with missing file", o: Options{Environment: AzureStackCloud, CloudConfigFile: filepath.Join(stackConfigDir, "missing.json")}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, err := resolveCloudConfig(c.o)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("resolveCloudConfig(%+v) = %+v, want error", c.o, cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCloudConfig(%+v) returned unexpected error: %v", c.o, err)
+			}
+			if cfg.ActiveDirectoryEndpoint == "" || cfg.GraphResourceID == "" {
+				t.Fatalf("resolveCloudConfig(%+v) = %+v, want non-empty endpoints", c.o, cfg)
+			}
+		})
+	}
+}
+
+func TestARCOBOEndpoint(t *testing.T) {
+	cloud := cloudConfigs[AzurePublicCloud]
+
+	cases := []struct {
+		name       string
+		region     string
+		resourceID string
+		want       string
+		wantErr    bool
+	}{
+		{name: "missing region", region: "", resourceID: "/subscriptions/sub/resourceGroups/rg", wantErr: true},
+		{name: "missing resourceID", region: "eastus", resourceID: "", wantErr: true},
+		{
+			name:       "valid",
+			region:     "eastus",
+			resourceID: "/subscriptions/sub/resourceGroups/rg",
+			want:       "https://eastus.obo.arc.azure.com/subscriptions/sub/resourceGroups/rg/getToken",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := cloud.ARCOBOEndpoint(c.region, c.resourceID)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("ARCOBOEndpoint(%q, %q) = %q, want error", c.region, c.resourceID, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ARCOBOEndpoint(%q, %q) returned unexpected error: %v", c.region, c.resourceID, err)
+			}
+			if got != c.want {
+				t.Fatalf("ARCOBOEndpoint(%q, %q) = %q, want %q", c.region, c.resourceID, got, c.want)
+			}
+		})
+	}
+}
+
+func TestVerifyClientIDAudience(t *testing.T) {
+	cases := []struct {
+		name          string
+		o             Options
+		tokenAudience string
+		want          bool
+		wantErr       bool
+	}{
+		{name: "verification disabled", o: Options{Environment: AzurePublicCloud, VerifyClientID: false}, tokenAudience: "anything", want: true},
+		{name: "bare clientID matches", o: Options{Environment: AzurePublicCloud, VerifyClientID: true, ClientID: "client-id"}, tokenAudience: "client-id", want: true},
+		{name: "api:// clientID matches", o: Options{Environment: AzurePublicCloud, VerifyClientID: true, ClientID: "client-id"}, tokenAudience: "api://client-id", want: true},
+		{name: "mismatched audience", o: Options{Environment: AzurePublicCloud, VerifyClientID: true, ClientID: "client-id"}, tokenAudience: "other-id", want: false},
+		{name: "unresolvable cloud", o: Options{Environment: "NotACloud", VerifyClientID: true, ClientID: "client-id"}, tokenAudience: "client-id", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := VerifyClientIDAudience(c.o, c.tokenAudience)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("VerifyClientIDAudience(%+v, %q) = %v, want error", c.o, c.tokenAudience, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyClientIDAudience(%+v, %q) returned unexpected error: %v", c.o, c.tokenAudience, err)
+			}
+			if got != c.want {
+				t.Fatalf("VerifyClientIDAudience(%+v, %q) = %v, want %v", c.o, c.tokenAudience, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// TokenCredential is guard's minimal equivalent of azcore.TokenCredential: a
+// source capable of producing a Graph API access token. envCredential,
+// workloadIdentityTokenProvider, managedIdentityTokenProvider and
+// azureCLICredential all implement it, which lets chainedCredential compose
+// them without knowing which auth mode backs each one.
+type TokenCredential interface {
+	GetToken(ctx context.Context) (token string, expiresOn time.Time, err error)
+}
+
+// NewTokenCredential builds the TokenCredential for o.AuthMode, covering
+// every auth mode that needs guard to acquire its own Graph API token:
+// workload-identity, managed-identity, arc, chained, client-credential and
+// obo. aks and passthrough are intentionally not covered here: aks serves
+// tokens through guard's existing AKS OBO flow (azure.aks-token-url) and
+// passthrough forwards the caller's own token, so neither acquires a
+// credential of guard's own the way the modes above do.
+func NewTokenCredential(o Options) (TokenCredential, error) {
+	switch o.AuthMode {
+	case WorkloadIdentityAuthMode:
+		return newWorkloadIdentityTokenProvider(o)
+	case ManagedIdentityAuthMode:
+		return newManagedIdentityTokenProvider(o)
+	case ARCAuthMode:
+		return newARCOBOTokenProvider(o)
+	case ChainedAuthMode:
+		return newChainedCredential(o)
+	case ClientCredentialAuthMode, OBOAuthMode:
+		return newEnvCredential(o)
+	case AKSAuthMode, PassthroughAuthMode:
+		return nil, errors.Errorf("azure.auth-mode %q is not served by TokenCredential; it does not acquire a credential of guard's own", o.AuthMode)
+	default:
+		return nil, errors.Errorf("azure.auth-mode %q has no TokenCredential implementation", o.AuthMode)
+	}
+}
+
+// chainedCredential tries each underlying credential in order and returns the
+// first token acquired successfully, mirroring azidentity's
+// ChainedTokenCredential. This backs ChainedAuthMode so operators get a
+// single auth mode that "just works" across AKS, ARC and a dev laptop
+// without reconfiguring --azure.auth-mode.
+type chainedCredential struct {
+	names       []string
+	credentials []TokenCredential
+}
+
+func newChainedCredential(o Options) (*chainedCredential, error) {
+	c := &chainedCredential{}
+
+	if o.ClientSecret != "" || o.ClientAssertion != "" || o.ClientAssertionFile != "" {
+		cred, err := newEnvCredential(o)
+		if err != nil {
+			return nil, err
+		}
+		c.add("env", cred)
+	}
+
+	if o.FederatedTokenFile != "" {
+		cred, err := newWorkloadIdentityTokenProvider(o)
+		if err != nil {
+			return nil, err
+		}
+		c.add("workload-identity", cred)
+	}
+
+	managedIdentityCred, err := newManagedIdentityTokenProvider(o)
+	if err != nil {
+		return nil, err
+	}
+	c.add("managed-identity", managedIdentityCred)
+
+	c.add("azure-cli", newAzureCLICredential(o))
+
+	if len(c.credentials) == 0 {
+		return nil, errors.New("chained azure.auth-mode could not assemble any usable credential")
+	}
+	return c, nil
+}
+
+func (c *chainedCredential) add(name string, cred TokenCredential) {
+	c.names = append(c.names, name)
+	c.credentials = append(c.credentials, cred)
+}
+
+// GetToken tries every configured credential in order, returning the first
+// token acquired successfully. All failures are logged and aggregated so the
+// operator can see why each candidate was skipped.
+func (c *chainedCredential) GetToken(ctx context.Context) (string, time.Time, error) {
+	var errs []string
+	for i, cred := range c.credentials {
+		token, expiresOn, err := cred.GetToken(ctx)
+		if err == nil {
+			return token, expiresOn, nil
+		}
+		klog.V(5).Infof("chained azure credential: %s did not produce a token: %v", c.names[i], err)
+		errs = append(errs, c.names[i]+": "+err.Error())
+	}
+	return "", time.Time{}, errors.Errorf("no credential in the chain could produce a token: %s", strings.Join(errs, "; "))
+}
+
+// envCredential acquires a token directly from the client secret or client
+// assertion supplied via flags/env. When backed by a client assertion file,
+// the acquired token is cached and reused across calls, refreshed whenever
+// it is within tokenRefreshSkew of expiry or the assertion file's generation
+// (bumped by clientAssertionFileSource's fsnotify watcher) has moved past
+// the generation the cached token was minted with.
+type envCredential struct {
+	tenantID        string
+	clientID        string
+	clientSecret    string
+	clientAssertion string
+	assertionFile   *clientAssertionFileSource
+	cloud           CloudConfig
+	httpClient      *http.Client
+
+	mu               sync.Mutex
+	cachedToken      string
+	cachedExpiresOn  time.Time
+	cachedGeneration uint64
+}
+
+func newEnvCredential(o Options) (*envCredential, error) {
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	e := &envCredential{
+		tenantID:        o.TenantID,
+		clientID:        o.ClientID,
+		clientSecret:    o.ClientSecret,
+		clientAssertion: o.ClientAssertion,
+		cloud:           cloud,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+	if o.ClientAssertionFile != "" {
+		e.assertionFile, err = newClientAssertionFileSource(o.ClientAssertionFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *envCredential) GetToken(ctx context.Context) (string, time.Time, error) {
+	if e.assertionFile == nil {
+		return requestClientCredentialToken(ctx, e.httpClient, e.cloud, e.tenantID, e.clientID, e.clientSecret, e.clientAssertion)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	generation := e.assertionFile.Generation()
+	if e.cachedToken != "" && generation == e.cachedGeneration && time.Until(e.cachedExpiresOn) > tokenRefreshSkew {
+		return e.cachedToken, e.cachedExpiresOn, nil
+	}
+
+	clientAssertion, err := e.assertionFile.readAssertion()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, expiresOn, err := requestClientCredentialToken(ctx, e.httpClient, e.cloud, e.tenantID, e.clientID, e.clientSecret, clientAssertion)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	e.cachedToken = token
+	e.cachedExpiresOn = expiresOn
+	e.cachedGeneration = generation
+	return token, expiresOn, nil
+}
+
+// azureCLICredential shells out to `az account get-access-token`, matching
+// azidentity's AzureCLICredential. It is the last, dev-laptop-oriented link
+// in the chain; if the az CLI is not on PATH, GetToken returns an error like
+// any other failed credential in the chain.
+type azureCLICredential struct {
+	resource string
+}
+
+func newAzureCLICredential(o Options) *azureCLICredential {
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		cloud = cloudConfigs[AzurePublicCloud]
+	}
+	return &azureCLICredential{resource: cloud.GraphResourceID}
+}
+
+func (a *azureCLICredential) GetToken(ctx context.Context) (string, time.Time, error) {
+	cmd := exec.CommandContext(ctx, "az", "account", "get-access-token",
+		"--resource", strings.TrimSuffix(a.resource, "/"),
+		"--query", "accessToken",
+		"--output", "tsv")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to get access token from az cli")
+	}
+	// az cli does not report an expiry via this output format; callers should
+	// re-invoke well before the default AAD token lifetime (1h) elapses.
+	return strings.TrimSpace(string(out)), time.Now().Add(45 * time.Minute), nil
+}
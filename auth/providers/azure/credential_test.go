@@ -0,0 +1,169 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewTokenCredentialAuthModeCoverage(t *testing.T) {
+	cases := []struct {
+		name    string
+		o       Options
+		wantErr bool
+	}{
+		{name: "workload-identity", o: Options{AuthMode: WorkloadIdentityAuthMode, Environment: AzurePublicCloud, ClientID: "client-id", FederatedTokenFile: "/var/run/secrets/token"}},
+		{name: "managed-identity", o: Options{AuthMode: ManagedIdentityAuthMode, Environment: AzurePublicCloud}},
+		{name: "chained", o: Options{AuthMode: ChainedAuthMode, Environment: AzurePublicCloud}},
+		{name: "client-credential", o: Options{AuthMode: ClientCredentialAuthMode, Environment: AzurePublicCloud, ClientSecret: "secret"}},
+		{name: "obo", o: Options{AuthMode: OBOAuthMode, Environment: AzurePublicCloud, ClientSecret: "secret"}},
+		{name: "arc", o: Options{AuthMode: ARCAuthMode, Environment: AzurePublicCloud, ResourceId: "/subscriptions/sub/resourceGroups/rg", AzureRegion: "eastus", ClientSecret: "secret"}},
+		{name: "aks is not covered by TokenCredential", o: Options{AuthMode: AKSAuthMode, Environment: AzurePublicCloud, AKSTokenURL: "https://aks.example/token"}, wantErr: true},
+		{name: "passthrough is not covered by TokenCredential", o: Options{AuthMode: PassthroughAuthMode, Environment: AzurePublicCloud}, wantErr: true},
+		{name: "unknown auth mode", o: Options{AuthMode: "not-a-mode", Environment: AzurePublicCloud}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cred, err := NewTokenCredential(c.o)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("NewTokenCredential(%+v) = %+v, want error", c.o, cred)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTokenCredential(%+v) returned unexpected error: %v", c.o, err)
+			}
+			if cred == nil {
+				t.Fatalf("NewTokenCredential(%+v) = nil credential, want non-nil", c.o)
+			}
+		})
+	}
+}
+
+type fakeCredential struct {
+	token     string
+	expiresOn time.Time
+	err       error
+}
+
+func (f *fakeCredential) GetToken(ctx context.Context) (string, time.Time, error) {
+	return f.token, f.expiresOn, f.err
+}
+
+func TestChainedCredentialGetToken(t *testing.T) {
+	t.Run("returns first successful token and stops trying further credentials", func(t *testing.T) {
+		expiresOn := time.Now().Add(time.Hour)
+		c := &chainedCredential{}
+		c.add("first", &fakeCredential{err: errors.New("first failed")})
+		c.add("second", &fakeCredential{token: "second-token", expiresOn: expiresOn})
+		c.add("third", &fakeCredential{token: "third-token", expiresOn: expiresOn})
+
+		token, got, err := c.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken() returned unexpected error: %v", err)
+		}
+		if token != "second-token" || !got.Equal(expiresOn) {
+			t.Fatalf("GetToken() = (%q, %v), want (%q, %v)", token, got, "second-token", expiresOn)
+		}
+	})
+
+	t.Run("aggregates every credential's failure in order when all fail", func(t *testing.T) {
+		c := &chainedCredential{}
+		c.add("first", &fakeCredential{err: errors.New("first failed")})
+		c.add("second", &fakeCredential{err: errors.New("second failed")})
+
+		_, _, err := c.GetToken(context.Background())
+		if err == nil {
+			t.Fatal("GetToken() = nil error, want aggregated error")
+		}
+		firstIdx := strings.Index(err.Error(), "first: first failed")
+		secondIdx := strings.Index(err.Error(), "second: second failed")
+		if firstIdx == -1 || secondIdx == -1 || firstIdx > secondIdx {
+			t.Fatalf("GetToken() error = %q, want both failures present in credential order", err.Error())
+		}
+	})
+}
+
+func TestEnvCredentialCachesTokenUntilAssertionFileRotates(t *testing.T) {
+	var requestCount atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requestCount.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+	}))
+	defer server.Close()
+
+	assertionPath := filepath.Join(t.TempDir(), "assertion.jwt")
+	if err := os.WriteFile(assertionPath, []byte("assertion-v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assertionFile, err := newClientAssertionFileSource(assertionPath)
+	if err != nil {
+		t.Fatalf("newClientAssertionFileSource() returned unexpected error: %v", err)
+	}
+
+	cred := &envCredential{
+		tenantID:      "tenant",
+		clientID:      "client",
+		assertionFile: assertionFile,
+		cloud:         CloudConfig{ActiveDirectoryEndpoint: server.URL, GraphResourceID: "https://graph.example/"},
+		httpClient:    server.Client(),
+	}
+
+	token, _, err := cred.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "token-1" {
+		t.Fatalf("GetToken() = %q, want %q", token, "token-1")
+	}
+
+	token, _, err = cred.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "token-1" || requestCount.Load() != 1 {
+		t.Fatalf("GetToken() = %q after %d requests, want cached %q after 1 request", token, requestCount.Load(), "token-1")
+	}
+
+	// Simulate the fsnotify watcher observing a file rotation without
+	// depending on watcher timing: bump the generation directly, the same
+	// effect watch() has on a real change event.
+	assertionFile.generation.Add(1)
+
+	token, _, err = cred.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "token-2" || requestCount.Load() != 2 {
+		t.Fatalf("GetToken() = %q after %d requests, want refreshed %q after 2 requests", token, requestCount.Load(), "token-2")
+	}
+}
@@ -19,6 +19,7 @@ package azure
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -37,6 +38,9 @@ const (
 	OBOAuthMode              = "obo"
 	ClientCredentialAuthMode = "client-credential"
 	PassthroughAuthMode      = "passthrough"
+	WorkloadIdentityAuthMode = "workload-identity"
+	ManagedIdentityAuthMode  = "managed-identity"
+	ChainedAuthMode          = "chained"
 )
 
 type Options struct {
@@ -57,13 +61,20 @@ type Options struct {
 	ResourceId                               string
 	AzureRegion                              string
 	HttpClientRetryCount                     int
+	FederatedTokenFile                       string
+	UserAssignedClientID                     string
+	CloudConfigFile                          string
+	ClientAssertionFile                      string
+	ClientAssertionTokenAudience             string
+	ClientAssertionTokenExpirationSeconds    int64
 }
 
 func NewOptions() Options {
 	return Options{
-		ClientSecret:    os.Getenv("AZURE_CLIENT_SECRET"),
-		ClientAssertion: os.Getenv("AZURE_CLIENT_ASSERTION"),
-		UseGroupUID:     true,
+		ClientSecret:       os.Getenv("AZURE_CLIENT_SECRET"),
+		ClientAssertion:    os.Getenv("AZURE_CLIENT_ASSERTION"),
+		FederatedTokenFile: os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		UseGroupUID:        true,
 	}
 }
 
@@ -86,10 +97,32 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.ResourceId, "azure.auth-resource-id", "", "azure cluster resource id (//subscription/<subName>/resourcegroups/<RGname>/providers/Microsoft.Kubernetes/connectedClusters/<clustername> for connectedk8s) used for making getMemberGroups to ARC OBO service")
 	fs.StringVar(&o.AzureRegion, "azure.region", "", "region where cluster is deployed")
 	fs.IntVar(&o.HttpClientRetryCount, "azure.http-client-retry-count", 2, "number of retries for retryablehttp client")
+	fs.StringVar(&o.FederatedTokenFile, "azure.federated-token-file", o.FederatedTokenFile, "path to the projected service account token file used for workload-identity auth mode; re-read on every token refresh")
+	fs.StringVar(&o.UserAssignedClientID, "azure.user-assigned-client-id", o.UserAssignedClientID, "client ID of the user-assigned managed identity to use for managed-identity auth mode; leave empty to use the system-assigned identity")
+	fs.StringVar(&o.CloudConfigFile, "azure.cloud-config-file", o.CloudConfigFile, "path to a JSON file describing the AAD, MS Graph and ARC OBO endpoints for azure.environment=AzureStackCloud")
+	fs.StringVar(&o.ClientAssertionFile, "azure.client-assertion-file", o.ClientAssertionFile, "path to a file containing the MS Graph application client assertion (JWT) to use; re-read on every token acquisition and watched for proactive refresh")
+	fs.StringVar(&o.ClientAssertionTokenAudience, "azure.client-assertion-token-audience", "api://AzureADTokenExchange", "audience claim to request for the projected service account token backing azure.client-assertion-file")
+	fs.Int64Var(&o.ClientAssertionTokenExpirationSeconds, "azure.client-assertion-token-expiration-seconds", 3600, "requested expiration, in seconds, for the projected service account token backing azure.client-assertion-file")
 }
 
 func (o *Options) Validate() []error {
 	var errs []error
+
+	if o.Environment == "" {
+		o.Environment = AzurePublicCloud
+	}
+	switch o.Environment {
+	case AzurePublicCloud:
+	case AzureUSGovernmentCloud:
+	case AzureChinaCloud:
+	case AzureStackCloud:
+		if o.CloudConfigFile == "" {
+			errs = append(errs, errors.New("azure.cloud-config-file must be non-empty when azure.environment=AzureStackCloud"))
+		}
+	default:
+		errs = append(errs, errors.Errorf("invalid azure.environment %q. valid value is one of AzurePublicCloud, AzureUSGovernmentCloud, AzureChinaCloud, AzureStackCloud", o.Environment))
+	}
+
 	o.AuthMode = strings.ToLower(o.AuthMode)
 	switch o.AuthMode {
 	case AKSAuthMode:
@@ -97,13 +130,32 @@ func (o *Options) Validate() []error {
 	case OBOAuthMode:
 	case ClientCredentialAuthMode:
 	case PassthroughAuthMode:
+	case WorkloadIdentityAuthMode:
+	case ManagedIdentityAuthMode:
+	case ChainedAuthMode:
 	default:
-		errs = append(errs, errors.New("invalid azure.auth-mode. valid value is either aks, obo, client-credential or passthrough"))
+		errs = append(errs, errors.New("invalid azure.auth-mode. valid value is either aks, arc, obo, client-credential, workload-identity, managed-identity, chained or passthrough"))
 	}
 
-	if o.AuthMode != AKSAuthMode && o.AuthMode != PassthroughAuthMode && o.AuthMode != ARCAuthMode {
-		if o.ClientSecret == "" && o.ClientAssertion == "" {
-			errs = append(errs, errors.New("azure.client-secret or azure.client-assertion must be non-empty"))
+	if o.ClientAssertion != "" && o.ClientAssertionFile != "" {
+		errs = append(errs, errors.New("only one of azure.client-assertion or azure.client-assertion-file can be set"))
+	}
+
+	if o.AuthMode != AKSAuthMode && o.AuthMode != PassthroughAuthMode && o.AuthMode != ARCAuthMode && o.AuthMode != WorkloadIdentityAuthMode && o.AuthMode != ManagedIdentityAuthMode && o.AuthMode != ChainedAuthMode {
+		if o.ClientSecret == "" && o.ClientAssertion == "" && o.ClientAssertionFile == "" {
+			errs = append(errs, errors.New("azure.client-secret, azure.client-assertion or azure.client-assertion-file must be non-empty"))
+		}
+	}
+
+	if o.AuthMode == WorkloadIdentityAuthMode {
+		if o.ClientSecret != "" || o.ClientAssertion != "" {
+			errs = append(errs, errors.New("azure.client-secret and azure.client-assertion must not be set when workload-identity azure.auth-mode is used"))
+		}
+		if o.ClientID == "" {
+			errs = append(errs, errors.New("azure.client-id must be non-empty when workload-identity azure.auth-mode is used"))
+		}
+		if o.FederatedTokenFile == "" {
+			errs = append(errs, errors.New("azure.federated-token-file must be non-empty when workload-identity azure.auth-mode is used"))
 		}
 	}
 	if o.AuthMode == AKSAuthMode && o.AKSTokenURL == "" {
@@ -151,78 +203,157 @@ func (o *Options) Validate() []error {
 func (o Options) Apply(d *apps.Deployment) (extraObjs []runtime.Object, err error) {
 	container := d.Spec.Template.Spec.Containers[0]
 
-	// create auth secret
-	authSecret := &core.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "guard-azure-auth",
-			Namespace: d.Namespace,
-			Labels:    d.Labels,
-		},
-		Data: map[string][]byte{
-			"client-secret":    []byte(o.ClientSecret),
-			"client-assertion": []byte(o.ClientAssertion),
-		},
-	}
-	extraObjs = append(extraObjs, authSecret)
-
-	// mount auth secret into deployment
-	volMount := core.VolumeMount{
-		Name:      authSecret.Name,
-		MountPath: "/etc/guard/auth/azure",
-	}
-	container.VolumeMounts = append(container.VolumeMounts, volMount)
-
-	vol := core.Volume{
-		Name: authSecret.Name,
-		VolumeSource: core.VolumeSource{
-			Secret: &core.SecretVolumeSource{
-				SecretName:  authSecret.Name,
-				DefaultMode: pointer.Int32P(0o555),
-			},
-		},
+	// guard-azure-auth is only needed when the operator supplied secret
+	// material directly (client-secret or a literal client-assertion); when
+	// the assertion instead comes from azure.client-assertion-file it is
+	// mounted as a projected service account token below and must not also
+	// be baked into this secret. workload-identity and managed-identity need
+	// no secret material at all.
+	needsSecretMount := o.ClientSecret != "" || o.ClientAssertion != ""
+	switch o.AuthMode {
+	case WorkloadIdentityAuthMode, ManagedIdentityAuthMode:
+		needsSecretMount = false
 	}
-	d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
 
-	// use auth secret in container[0] args
-	container.Env = append(container.Env, core.EnvVar{
-		Name: "AZURE_CLIENT_SECRET",
-		ValueFrom: &core.EnvVarSource{
-			SecretKeyRef: &core.SecretKeySelector{
-				LocalObjectReference: core.LocalObjectReference{
-					Name: authSecret.Name,
+	if o.AuthMode == WorkloadIdentityAuthMode {
+		// workload-identity relies on the projected service account token mounted by
+		// the Azure Workload Identity mutating webhook; guard itself mounts no secret.
+		container.Env = append(container.Env, core.EnvVar{
+			Name:  "AZURE_FEDERATED_TOKEN_FILE",
+			Value: o.FederatedTokenFile,
+		})
+	} else if o.AuthMode == ManagedIdentityAuthMode {
+		// managed-identity resolves tokens from the node's IMDS endpoint; guard
+		// needs no secret material, just the flags set below.
+	} else if needsSecretMount {
+		// when the assertion is sourced from a file, it is mounted as a
+		// projected service account token below and must not also be baked
+		// into the guard-azure-auth secret.
+		authSecretData := map[string][]byte{
+			"client-secret": []byte(o.ClientSecret),
+		}
+		if o.ClientAssertionFile == "" {
+			authSecretData["client-assertion"] = []byte(o.ClientAssertion)
+		}
+
+		// create auth secret
+		authSecret := &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "guard-azure-auth",
+				Namespace: d.Namespace,
+				Labels:    d.Labels,
+			},
+			Data: authSecretData,
+		}
+		extraObjs = append(extraObjs, authSecret)
+
+		// mount auth secret into deployment
+		volMount := core.VolumeMount{
+			Name:      authSecret.Name,
+			MountPath: "/etc/guard/auth/azure",
+		}
+		container.VolumeMounts = append(container.VolumeMounts, volMount)
+
+		vol := core.Volume{
+			Name: authSecret.Name,
+			VolumeSource: core.VolumeSource{
+				Secret: &core.SecretVolumeSource{
+					SecretName:  authSecret.Name,
+					DefaultMode: pointer.Int32P(0o555),
 				},
-				Key: "client-secret",
 			},
-		},
-	})
-	container.Env = append(container.Env, core.EnvVar{
-		Name: "AZURE_CLIENT_ASSERTION",
-		ValueFrom: &core.EnvVarSource{
-			SecretKeyRef: &core.SecretKeySelector{
-				LocalObjectReference: core.LocalObjectReference{
-					Name: authSecret.Name,
+		}
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
+
+		// use auth secret in container[0] args
+		container.Env = append(container.Env, core.EnvVar{
+			Name: "AZURE_CLIENT_SECRET",
+			ValueFrom: &core.EnvVarSource{
+				SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{
+						Name: authSecret.Name,
+					},
+					Key: "client-secret",
 				},
-				Key: "client-assertion",
 			},
-		},
-	})
+		})
+		if o.ClientAssertionFile == "" {
+			container.Env = append(container.Env, core.EnvVar{
+				Name: "AZURE_CLIENT_ASSERTION",
+				ValueFrom: &core.EnvVarSource{
+					SecretKeyRef: &core.SecretKeySelector{
+						LocalObjectReference: core.LocalObjectReference{
+							Name: authSecret.Name,
+						},
+						Key: "client-assertion",
+					},
+				},
+			})
+		}
+	}
+
+	if o.ClientAssertionFile != "" {
+		// source the assertion from a projected, auto-rotating service account
+		// token instead of baking a static JWT into the guard-azure-auth secret.
+		const clientAssertionVolumeName = "guard-azure-client-assertion"
+		clientAssertionDir := filepath.Dir(o.ClientAssertionFile)
+		clientAssertionFileName := filepath.Base(o.ClientAssertionFile)
+
+		container.VolumeMounts = append(container.VolumeMounts, core.VolumeMount{
+			Name:      clientAssertionVolumeName,
+			MountPath: clientAssertionDir,
+			ReadOnly:  true,
+		})
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, core.Volume{
+			Name: clientAssertionVolumeName,
+			VolumeSource: core.VolumeSource{
+				Projected: &core.ProjectedVolumeSource{
+					Sources: []core.VolumeProjection{
+						{
+							ServiceAccountToken: &core.ServiceAccountTokenProjection{
+								Audience:          o.ClientAssertionTokenAudience,
+								ExpirationSeconds: pointer.Int64P(o.ClientAssertionTokenExpirationSeconds),
+								Path:              clientAssertionFileName,
+							},
+						},
+					},
+				},
+			},
+		})
+		container.Env = append(container.Env, core.EnvVar{
+			Name:  "AZURE_CLIENT_ASSERTION_FILE",
+			Value: o.ClientAssertionFile,
+		})
+	}
 
 	args := container.Args
 	if o.Environment != "" {
 		args = append(args, fmt.Sprintf("--azure.environment=%s", o.Environment))
 	}
+	if o.CloudConfigFile != "" {
+		args = append(args, fmt.Sprintf("--azure.cloud-config-file=%s", o.CloudConfigFile))
+	}
 	if o.ClientID != "" {
 		args = append(args, fmt.Sprintf("--azure.client-id=%s", o.ClientID))
 	}
 	if o.TenantID != "" {
 		args = append(args, fmt.Sprintf("--azure.tenant-id=%s", o.TenantID))
 	}
+	if o.ClientAssertionFile != "" {
+		args = append(args, fmt.Sprintf("--azure.client-assertion-file=%s", o.ClientAssertionFile))
+	}
 
 	switch o.AuthMode {
 	case AKSAuthMode:
 		fallthrough
 	case OBOAuthMode:
 		fallthrough
+	case WorkloadIdentityAuthMode:
+		fallthrough
+	case ManagedIdentityAuthMode:
+		fallthrough
+	case ChainedAuthMode:
+		fallthrough
 	case ClientCredentialAuthMode:
 		args = append(args, fmt.Sprintf("--azure.auth-mode=%s", o.AuthMode))
 	default:
@@ -233,6 +364,14 @@ func (o Options) Apply(d *apps.Deployment) (extraObjs []runtime.Object, err erro
 		args = append(args, fmt.Sprintf("--azure.aks-token-url=%s", o.AKSTokenURL))
 	}
 
+	if (o.AuthMode == WorkloadIdentityAuthMode || o.AuthMode == ChainedAuthMode) && o.FederatedTokenFile != "" {
+		args = append(args, fmt.Sprintf("--azure.federated-token-file=%s", o.FederatedTokenFile))
+	}
+
+	if (o.AuthMode == ManagedIdentityAuthMode || o.AuthMode == ChainedAuthMode) && o.UserAssignedClientID != "" {
+		args = append(args, fmt.Sprintf("--azure.user-assigned-client-id=%s", o.UserAssignedClientID))
+	}
+
 	args = append(args, fmt.Sprintf("--azure.use-group-uid=%t", o.UseGroupUID))
 
 	args = append(args, fmt.Sprintf("--azure.graph-call-on-overage-claim=%t", o.ResolveGroupMembershipOnlyOnOverageClaim))
@@ -0,0 +1,215 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+)
+
+func newTestDeployment() *apps.Deployment {
+	return &apps.Deployment{
+		Spec: apps.DeploymentSpec{
+			Template: core.PodTemplateSpec{
+				Spec: core.PodSpec{
+					Containers: []core.Container{{Name: "guard"}},
+				},
+			},
+		},
+	}
+}
+
+func hasSecretVolume(d *apps.Deployment) bool {
+	for _, v := range d.Spec.Template.Spec.Volumes {
+		if v.Name == "guard-azure-auth" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestApplyClientAssertionFileDoesNotMountAuthSecret(t *testing.T) {
+	o := Options{
+		AuthMode:                              ClientCredentialAuthMode,
+		TenantID:                              "tenant-id",
+		ClientID:                              "client-id",
+		ClientAssertionFile:                   "/var/run/secrets/azure/assertion",
+		ClientAssertionTokenAudience:          "api://AzureADTokenExchange",
+		ClientAssertionTokenExpirationSeconds: 3600,
+	}
+	d := newTestDeployment()
+
+	if _, err := o.Apply(d); err != nil {
+		t.Fatalf("Apply() returned unexpected error: %v", err)
+	}
+	if hasSecretVolume(d) {
+		t.Fatalf("Apply() mounted guard-azure-auth secret when only azure.client-assertion-file was set")
+	}
+}
+
+func TestApplyClientSecretMountsAuthSecret(t *testing.T) {
+	o := Options{
+		AuthMode:     ClientCredentialAuthMode,
+		TenantID:     "tenant-id",
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+	}
+	d := newTestDeployment()
+
+	if _, err := o.Apply(d); err != nil {
+		t.Fatalf("Apply() returned unexpected error: %v", err)
+	}
+	if !hasSecretVolume(d) {
+		t.Fatalf("Apply() did not mount guard-azure-auth secret when azure.client-secret was set")
+	}
+}
+
+func validOptions() Options {
+	return Options{
+		Environment:                              AzurePublicCloud,
+		AuthMode:                                 AKSAuthMode,
+		AKSTokenURL:                              "https://aks.example/token",
+		TenantID:                                 "tenant-id",
+		ResolveGroupMembershipOnlyOnOverageClaim: true,
+		SkipGroupMembershipResolution:            true,
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(o *Options)
+		wantErr bool
+	}{
+		{name: "empty defaults to public cloud", mutate: func(o *Options) { o.Environment = "" }},
+		{name: "public cloud", mutate: func(o *Options) { o.Environment = AzurePublicCloud }},
+		{name: "us government cloud", mutate: func(o *Options) { o.Environment = AzureUSGovernmentCloud }},
+		{name: "china cloud", mutate: func(o *Options) { o.Environment = AzureChinaCloud }},
+		{name: "azure stack cloud without cloud config file", mutate: func(o *Options) { o.Environment = AzureStackCloud }, wantErr: true},
+		{name: "azure stack cloud with cloud config file", mutate: func(o *Options) {
+			o.Environment = AzureStackCloud
+			o.CloudConfigFile = "/etc/azurestack/cloud-config.json"
+		}},
+		{name: "unknown environment", mutate: func(o *Options) { o.Environment = "NotACloud" }, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := validOptions()
+			c.mutate(&o)
+			errs := o.Validate()
+			if c.wantErr && len(errs) == 0 {
+				t.Fatalf("Validate() = no errors, want an error for %+v", o)
+			}
+			if !c.wantErr && len(errs) != 0 {
+				t.Fatalf("Validate() = %v, want no errors for %+v", errs, o)
+			}
+		})
+	}
+}
+
+func TestValidateAuthMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(o *Options)
+		wantErr bool
+	}{
+		{name: "aks mode", mutate: func(o *Options) {
+			o.AuthMode = AKSAuthMode
+			o.AKSTokenURL = "https://aks.example/token"
+		}},
+		{name: "aks mode without token url", mutate: func(o *Options) {
+			o.AuthMode = AKSAuthMode
+			o.AKSTokenURL = ""
+		}, wantErr: true},
+		{name: "client-credential mode with secret", mutate: func(o *Options) {
+			o.AuthMode = ClientCredentialAuthMode
+			o.ClientSecret = "secret"
+		}},
+		{name: "client-credential mode without secret or assertion", mutate: func(o *Options) {
+			o.AuthMode = ClientCredentialAuthMode
+		}, wantErr: true},
+		{name: "both client-assertion and client-assertion-file set", mutate: func(o *Options) {
+			o.AuthMode = ClientCredentialAuthMode
+			o.ClientAssertion = "assertion"
+			o.ClientAssertionFile = "/var/run/secrets/assertion"
+		}, wantErr: true},
+		{name: "passthrough mode with required flags", mutate: func(o *Options) {
+			o.AuthMode = PassthroughAuthMode
+			o.ResolveGroupMembershipOnlyOnOverageClaim = true
+			o.SkipGroupMembershipResolution = true
+		}},
+		{name: "passthrough mode missing required flags", mutate: func(o *Options) {
+			o.AuthMode = PassthroughAuthMode
+			o.ResolveGroupMembershipOnlyOnOverageClaim = false
+			o.SkipGroupMembershipResolution = false
+		}, wantErr: true},
+		{name: "workload-identity mode with required fields", mutate: func(o *Options) {
+			o.AuthMode = WorkloadIdentityAuthMode
+			o.ClientID = "client-id"
+			o.FederatedTokenFile = "/var/run/secrets/token"
+		}},
+		{name: "workload-identity mode missing federated token file", mutate: func(o *Options) {
+			o.AuthMode = WorkloadIdentityAuthMode
+			o.ClientID = "client-id"
+			o.FederatedTokenFile = ""
+		}, wantErr: true},
+		{name: "workload-identity mode with client secret set", mutate: func(o *Options) {
+			o.AuthMode = WorkloadIdentityAuthMode
+			o.ClientID = "client-id"
+			o.FederatedTokenFile = "/var/run/secrets/token"
+			o.ClientSecret = "secret"
+		}, wantErr: true},
+		{name: "managed-identity mode", mutate: func(o *Options) {
+			o.AuthMode = ManagedIdentityAuthMode
+		}},
+		{name: "chained mode", mutate: func(o *Options) {
+			o.AuthMode = ChainedAuthMode
+		}},
+		{name: "arc mode with required fields", mutate: func(o *Options) {
+			o.AuthMode = ARCAuthMode
+			o.ResourceId = "/subscriptions/sub/resourceGroups/rg"
+			o.AzureRegion = "eastus"
+			o.ResolveGroupMembershipOnlyOnOverageClaim = true
+			o.SkipGroupMembershipResolution = false
+		}},
+		{name: "arc mode missing resource id and region", mutate: func(o *Options) {
+			o.AuthMode = ARCAuthMode
+			o.ResourceId = ""
+			o.AzureRegion = ""
+			o.ResolveGroupMembershipOnlyOnOverageClaim = true
+			o.SkipGroupMembershipResolution = false
+		}, wantErr: true},
+		{name: "invalid auth mode", mutate: func(o *Options) { o.AuthMode = "not-a-mode" }, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := validOptions()
+			c.mutate(&o)
+			errs := o.Validate()
+			if c.wantErr && len(errs) == 0 {
+				t.Fatalf("Validate() = no errors, want an error for %+v", o)
+			}
+			if !c.wantErr && len(errs) != 0 {
+				t.Fatalf("Validate() = %v, want no errors for %+v", errs, o)
+			}
+		})
+	}
+}
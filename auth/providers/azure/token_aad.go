@@ -0,0 +1,79 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// requestClientCredentialToken exchanges a client secret or client assertion
+// for a Graph API access token via the AAD v2.0 token endpoint. It backs
+// envCredential (client secret, literal assertion or assertion file) and
+// workloadIdentityTokenProvider (federated token file). A non-empty
+// clientSecret takes precedence over clientAssertion.
+func requestClientCredentialToken(ctx context.Context, httpClient *http.Client, cloud CloudConfig, tenantID, clientID, clientSecret, clientAssertion string) (string, time.Time, error) {
+	tokenURL := cloud.ActiveDirectoryEndpoint + "/" + tenantID + "/oauth2/v2.0/token"
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("scope", cloud.GraphResourceID+".default")
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	} else {
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", clientAssertion)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to build azure ad token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to call azure ad token endpoint")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to read azure ad token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, errors.Errorf("azure ad token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to unmarshal azure ad token response")
+	}
+
+	return tokenResp.AccessToken, time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second), nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// arcOBOTokenProvider acquires Graph API tokens for Arc-connected clusters by
+// exchanging an inner AAD token (acquired via the env credential) with the
+// cluster's ARC Hybrid Connectivity on-behalf-of service, whose endpoint is
+// cloud- and region-specific.
+type arcOBOTokenProvider struct {
+	inner      TokenCredential
+	endpoint   string
+	httpClient *http.Client
+}
+
+func newARCOBOTokenProvider(o Options) (*arcOBOTokenProvider, error) {
+	if o.ResourceId == "" || o.AzureRegion == "" {
+		return nil, errors.New("azure.auth-resource-id and azure.region must be non-empty for arc azure.auth-mode")
+	}
+
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := cloud.ARCOBOEndpoint(o.AzureRegion, o.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err := newEnvCredential(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &arcOBOTokenProvider{
+		inner:      inner,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// GetToken first acquires an AAD token for guard's own identity, then
+// exchanges it with the ARC OBO service for a token scoped to the connected
+// cluster's managed identity.
+func (a *arcOBOTokenProvider) GetToken(ctx context.Context) (string, time.Time, error) {
+	innerToken, _, err := a.inner.GetToken(ctx)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to acquire inner token for arc obo exchange")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, nil)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to build arc obo request")
+	}
+	req.Header.Set("Authorization", "Bearer "+innerToken)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to call arc obo endpoint")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to read arc obo response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, errors.Errorf("arc obo endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to unmarshal arc obo response")
+	}
+
+	return tokenResp.AccessToken, time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second), nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// clientAssertionFileSource reads a client assertion (JWT) from disk. A
+// fsnotify watcher on the containing directory (not the file itself, since
+// Kubernetes replaces projected volume files via an atomic symlink swap)
+// bumps a generation counter on every change; envCredential compares this
+// against the generation it last minted a token for to invalidate its cache
+// as soon as the file rotates, instead of waiting for the token to expire.
+type clientAssertionFileSource struct {
+	path       string
+	generation atomic.Uint64
+}
+
+// Generation returns the number of fsnotify events seen in the assertion
+// file's directory since this source was created.
+func (s *clientAssertionFileSource) Generation() uint64 {
+	return s.generation.Load()
+}
+
+func newClientAssertionFileSource(path string) (*clientAssertionFileSource, error) {
+	s := &clientAssertionFileSource{path: path}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create fsnotify watcher for azure.client-assertion-file")
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, errors.Wrapf(err, "failed to watch directory of azure.client-assertion-file %s", path)
+	}
+
+	go s.watch(watcher)
+
+	return s, nil
+}
+
+// watch bumps generation on every event in the watched directory, rather
+// than filtering for an event naming s.path's own file name. Kubernetes
+// rotates a projected volume by writing a new "..<timestamp>" directory and
+// atomically re-pointing the "..data" symlink at it; the path the caller
+// reads through (and its final path component) never appears in an event of
+// its own, so any name-based filter here would silently never fire.
+func (s *clientAssertionFileSource) watch(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			s.generation.Add(1)
+			klog.V(5).Infof("azure.client-assertion-file %s directory changed, generation now %d", s.path, s.generation.Load())
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Warningf("error watching azure.client-assertion-file %s: %v", s.path, err)
+		}
+	}
+}
+
+// readAssertion always re-reads the file from disk; callers that want to
+// avoid reading on every token acquisition should consult Generation instead.
+func (s *clientAssertionFileSource) readAssertion() (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read azure.client-assertion-file %s", s.path)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestClientAssertionFileSourceGenerationBumpsOnProjectedVolumeRotation
+// reproduces how Kubernetes rotates a projected service account token
+// volume: a new "..<timestamp>" directory is populated, then the "..data"
+// symlink is atomically re-pointed at it via a rename. The path callers
+// read through (dir/assertion -> ..data/assertion) never changes name
+// itself, so watch() must react to the rename rather than filtering on
+// assertion's own file name.
+func TestClientAssertionFileSourceGenerationBumpsOnProjectedVolumeRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldDataDir := filepath.Join(dir, "..2024_01_01_00_00_00.000000000")
+	if err := os.Mkdir(oldDataDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDataDir, "assertion"), []byte("assertion-v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dataSymlink := filepath.Join(dir, "..data")
+	if err := os.Symlink(oldDataDir, dataSymlink); err != nil {
+		t.Fatal(err)
+	}
+	assertionPath := filepath.Join(dir, "assertion")
+	if err := os.Symlink(filepath.Join("..data", "assertion"), assertionPath); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newClientAssertionFileSource(assertionPath)
+	if err != nil {
+		t.Fatalf("newClientAssertionFileSource() returned unexpected error: %v", err)
+	}
+
+	startGeneration := s.Generation()
+
+	newDataDir := filepath.Join(dir, "..2024_01_01_00_01_00.000000000")
+	if err := os.Mkdir(newDataDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(newDataDir, "assertion"), []byte("assertion-v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpSymlink := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(newDataDir, tmpSymlink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpSymlink, dataSymlink); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if s.Generation() > startGeneration {
+			break
+		}
+		select {
+		case <-tick.C:
+			continue
+		case <-deadline:
+			t.Fatalf("Generation() = %d after rotation, want > %d within 5s", s.Generation(), startGeneration)
+		}
+	}
+
+	got, err := s.readAssertion()
+	if err != nil {
+		t.Fatalf("readAssertion() returned unexpected error: %v", err)
+	}
+	if got != "assertion-v2" {
+		t.Fatalf("readAssertion() = %q, want %q after rotation", got, "assertion-v2")
+	}
+}
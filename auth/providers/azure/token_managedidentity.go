@@ -0,0 +1,128 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	imdsTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	imdsAPIVersion    = "2018-02-01"
+	// tokenRefreshSkew is how far ahead of expiry a cached token is refreshed.
+	tokenRefreshSkew = 5 * time.Minute
+)
+
+// managedIdentityTokenProvider acquires Graph API tokens from the Azure
+// Instance Metadata Service (IMDS) using a system- or user-assigned managed
+// identity. Tokens are cached in-memory and refreshed once they are within
+// tokenRefreshSkew of their expires_on time.
+type managedIdentityTokenProvider struct {
+	userAssignedClientID string
+	graphResource        string
+	endpoint             string
+	httpClient           *http.Client
+
+	mu          sync.Mutex
+	cachedToken string
+	expiresOn   time.Time
+}
+
+func newManagedIdentityTokenProvider(o Options) (*managedIdentityTokenProvider, error) {
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	return &managedIdentityTokenProvider{
+		userAssignedClientID: o.UserAssignedClientID,
+		graphResource:        cloud.GraphResourceID,
+		endpoint:             imdsTokenEndpoint,
+		httpClient:           &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// GetToken returns a cached Graph API token, refreshing it from IMDS if it is
+// missing or about to expire.
+func (m *managedIdentityTokenProvider) GetToken(ctx context.Context) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cachedToken != "" && time.Until(m.expiresOn) > tokenRefreshSkew {
+		return m.cachedToken, m.expiresOn, nil
+	}
+
+	token, expiresOn, err := m.requestToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	m.cachedToken = token
+	m.expiresOn = expiresOn
+	return token, expiresOn, nil
+}
+
+func (m *managedIdentityTokenProvider) requestToken(ctx context.Context) (string, time.Time, error) {
+	q := url.Values{}
+	q.Set("api-version", imdsAPIVersion)
+	q.Set("resource", m.graphResource)
+	if m.userAssignedClientID != "" {
+		q.Set("client_id", m.userAssignedClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to build imds token request")
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to call imds token endpoint")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to read imds token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, errors.Errorf("imds token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to unmarshal imds token response")
+	}
+
+	expiresOnUnix, err := strconv.ParseInt(tokenResp.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to parse imds expires_on")
+	}
+
+	return tokenResp.AccessToken, time.Unix(expiresOnUnix, 0), nil
+}
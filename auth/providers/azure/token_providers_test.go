@@ -0,0 +1,225 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkloadIdentityTokenProviderGetToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.FormValue("client_assertion"); got != "federated-token" {
+			t.Errorf("client_assertion = %q, want %q", got, "federated-token")
+		}
+		if got := r.FormValue("client_assertion_type"); got != "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" {
+			t.Errorf("client_assertion_type = %q, want jwt-bearer", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"graph-token","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "federated-token")
+	if err := os.WriteFile(tokenFile, []byte("federated-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &workloadIdentityTokenProvider{
+		tenantID:      "tenant",
+		clientID:      "client",
+		tokenFilePath: tokenFile,
+		cloud:         CloudConfig{ActiveDirectoryEndpoint: server.URL, GraphResourceID: "https://graph.example/"},
+		httpClient:    server.Client(),
+	}
+
+	token, expiresOn, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "graph-token" {
+		t.Fatalf("GetToken() token = %q, want %q", token, "graph-token")
+	}
+	if !expiresOn.After(time.Now()) {
+		t.Fatalf("GetToken() expiresOn = %v, want a time in the future", expiresOn)
+	}
+}
+
+func TestWorkloadIdentityTokenProviderGetTokenMissingFile(t *testing.T) {
+	p := &workloadIdentityTokenProvider{
+		tenantID:      "tenant",
+		clientID:      "client",
+		tokenFilePath: filepath.Join(t.TempDir(), "missing"),
+		cloud:         CloudConfig{ActiveDirectoryEndpoint: "https://login.example", GraphResourceID: "https://graph.example/"},
+		httpClient:    http.DefaultClient,
+	}
+
+	if _, _, err := p.GetToken(context.Background()); err == nil {
+		t.Fatal("GetToken() = nil error, want error for missing federated token file")
+	}
+}
+
+func TestManagedIdentityTokenProviderGetToken(t *testing.T) {
+	var requestCount atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		if got := r.Header.Get("Metadata"); got != "true" {
+			t.Errorf("Metadata header = %q, want %q", got, "true")
+		}
+		if got := r.URL.Query().Get("resource"); got != "https://graph.example/" {
+			t.Errorf("resource query param = %q, want %q", got, "https://graph.example/")
+		}
+		if got := r.URL.Query().Get("client_id"); got != "user-assigned-id" {
+			t.Errorf("client_id query param = %q, want %q", got, "user-assigned-id")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"imds-token","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	p := &managedIdentityTokenProvider{
+		userAssignedClientID: "user-assigned-id",
+		graphResource:        "https://graph.example/",
+		endpoint:             server.URL,
+		httpClient:           server.Client(),
+	}
+
+	token, expiresOn, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "imds-token" {
+		t.Fatalf("GetToken() token = %q, want %q", token, "imds-token")
+	}
+	if !expiresOn.After(time.Now()) {
+		t.Fatalf("GetToken() expiresOn = %v, want a time in the future", expiresOn)
+	}
+	if requestCount.Load() != 1 {
+		t.Fatalf("IMDS request count = %d, want 1", requestCount.Load())
+	}
+
+	// A second call while the cached token is still well outside
+	// tokenRefreshSkew of expiry should be served from cache, not IMDS.
+	cached, cachedExpiresOn, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if cached != token || !cachedExpiresOn.Equal(expiresOn) {
+		t.Fatalf("GetToken() = (%q, %v), want cached (%q, %v)", cached, cachedExpiresOn, token, expiresOn)
+	}
+	if requestCount.Load() != 1 {
+		t.Fatalf("IMDS request count = %d after second GetToken(), want 1 (should have served the cache)", requestCount.Load())
+	}
+}
+
+func TestManagedIdentityTokenProviderGetTokenRefreshesNearExpiry(t *testing.T) {
+	var requestCount atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"refreshed-token","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	p := &managedIdentityTokenProvider{
+		graphResource: "https://graph.example/",
+		endpoint:      server.URL,
+		httpClient:    server.Client(),
+		cachedToken:   "stale-token",
+		expiresOn:     time.Now().Add(tokenRefreshSkew - time.Minute),
+	}
+
+	token, _, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "refreshed-token" {
+		t.Fatalf("GetToken() = %q, want refresh to %q since the cached token is within tokenRefreshSkew of expiry", token, "refreshed-token")
+	}
+	if requestCount.Load() != 1 {
+		t.Fatalf("IMDS request count = %d, want 1", requestCount.Load())
+	}
+}
+
+func TestArcOBOTokenProviderGetToken(t *testing.T) {
+	var innerTokenSeen string
+
+	aadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"inner-token","expires_in":3600}`)
+	}))
+	defer aadServer.Close()
+
+	oboServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		innerTokenSeen = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"arc-obo-token","expires_in":3600}`)
+	}))
+	defer oboServer.Close()
+
+	inner := &envCredential{
+		tenantID:     "tenant",
+		clientID:     "client",
+		clientSecret: "secret",
+		cloud:        CloudConfig{ActiveDirectoryEndpoint: aadServer.URL, GraphResourceID: "https://graph.example/"},
+		httpClient:   aadServer.Client(),
+	}
+	p := &arcOBOTokenProvider{
+		inner:      inner,
+		endpoint:   oboServer.URL,
+		httpClient: oboServer.Client(),
+	}
+
+	token, expiresOn, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken() returned unexpected error: %v", err)
+	}
+	if token != "arc-obo-token" {
+		t.Fatalf("GetToken() token = %q, want %q", token, "arc-obo-token")
+	}
+	if !expiresOn.After(time.Now()) {
+		t.Fatalf("GetToken() expiresOn = %v, want a time in the future", expiresOn)
+	}
+	if innerTokenSeen != "Bearer inner-token" {
+		t.Fatalf("arc obo request Authorization header = %q, want %q", innerTokenSeen, "Bearer inner-token")
+	}
+}
+
+func TestArcOBOTokenProviderGetTokenInnerFailure(t *testing.T) {
+	inner := &fakeCredential{err: fmt.Errorf("inner credential unavailable")}
+	p := &arcOBOTokenProvider{
+		inner:      inner,
+		endpoint:   "http://unused.invalid",
+		httpClient: http.DefaultClient,
+	}
+
+	if _, _, err := p.GetToken(context.Background()); err == nil {
+		t.Fatal("GetToken() = nil error, want error when the inner credential fails")
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright The Guard Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// workloadIdentityTokenProvider acquires Graph API tokens via the Azure AD
+// client-assertion flow, using a projected Kubernetes service-account JWT
+// (mounted by the Azure Workload Identity admission webhook) as the assertion.
+// The assertion file is re-read on every call to GetToken since the projected
+// token is periodically rotated by the kubelet.
+type workloadIdentityTokenProvider struct {
+	tenantID      string
+	clientID      string
+	tokenFilePath string
+	cloud         CloudConfig
+	httpClient    *http.Client
+}
+
+func newWorkloadIdentityTokenProvider(o Options) (*workloadIdentityTokenProvider, error) {
+	cloud, err := resolveCloudConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	return &workloadIdentityTokenProvider{
+		tenantID:      o.TenantID,
+		clientID:      o.ClientID,
+		tokenFilePath: o.FederatedTokenFile,
+		cloud:         cloud,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// readAssertion reads the federated token file from disk. It is intentionally
+// not cached in memory since Azure Workload Identity rotates the projected
+// token well before it expires.
+func (w *workloadIdentityTokenProvider) readAssertion() (string, error) {
+	data, err := os.ReadFile(w.tokenFilePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read azure federated token file %s", w.tokenFilePath)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// GetToken exchanges the federated service-account token for a Graph API
+// access token using the AAD v2.0 client-credentials/client-assertion flow.
+func (w *workloadIdentityTokenProvider) GetToken(ctx context.Context) (string, time.Time, error) {
+	assertion, err := w.readAssertion()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return requestClientCredentialToken(ctx, w.httpClient, w.cloud, w.tenantID, w.clientID, "", assertion)
+}